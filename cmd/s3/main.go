@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/backends/s3"
+	"github.com/Isonami/terraform-state-wrapper/pkg/encconfig"
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	args, allowPlaintextRead := encconfig.ExtractAllowPlaintextReadFlag(os.Args[1:])
+
+	backend, err := encconfig.Wrap(ctx, new(s3.Backend), allowPlaintextRead)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wrapper.Wrap(ctx, backend, args)
+}
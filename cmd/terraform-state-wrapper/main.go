@@ -2,16 +2,112 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
 	"github.com/Isonami/terraform-state-wrapper/pkg/backends/file"
+	"github.com/Isonami/terraform-state-wrapper/pkg/encconfig"
+	"github.com/Isonami/terraform-state-wrapper/pkg/history"
 	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
-	"os"
 )
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	backend := new(file.Backend)
+	args, allowPlaintextRead := encconfig.ExtractAllowPlaintextReadFlag(os.Args[1:])
+
+	backend, err := buildBackend(ctx, allowPlaintextRead)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "history" {
+		if err := runHistory(ctx, backend, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	wrapper.Wrap(ctx, backend, args)
+}
+
+// buildBackend assembles the file backend, with history always on, and
+// encryption layered on top when TF_STATE_WRAPPER_ENC_MODE selects it.
+func buildBackend(ctx context.Context, allowPlaintextRead bool) (wrapper.Backend, error) {
+	var backend wrapper.Backend = history.Wrap(new(file.Backend))
+	return encconfig.Wrap(ctx, backend, allowPlaintextRead)
+}
+
+// runHistory serves `wrapper history list|show <id>|rollback <id>`, which
+// bypass the terraform child process entirely and operate directly on the
+// backend. It honours TF_WORKSPACE the same way Wrap does, so history
+// commands act on the workspace the caller actually has selected rather than
+// always the default one.
+func runHistory(ctx context.Context, backend wrapper.Backend, args []string) error {
+	if err := backend.Config(ctx); err != nil {
+		return err
+	}
+
+	workspace := os.Getenv("TF_WORKSPACE")
+	if workspace == "" {
+		workspace = wrapper.DefaultWorkspace
+	}
+	backend = backend.ForWorkspace(workspace)
+
+	versioned, ok := backend.(wrapper.VersionedBackend)
+	if !ok {
+		return errors.New("history: backend does not support history")
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: wrapper history list|show <id>|rollback <id>")
+	}
+
+	switch args[0] {
+	case "list":
+		versions, err := versioned.ListVersions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			fmt.Printf("%s\t%s\t%s\t%s\n", version.ID, version.Created.Format(time.RFC3339), version.Who, version.Comment)
+		}
+		return nil
+
+	case "show":
+		if len(args) < 2 {
+			return errors.New("usage: wrapper history show <id>")
+		}
+		data, err := versioned.GetVersion(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return fmt.Errorf("no such history version %q", args[1])
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+
+	case "rollback":
+		if len(args) < 2 {
+			return errors.New("usage: wrapper history rollback <id>")
+		}
+		data, err := versioned.GetVersion(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			return fmt.Errorf("no such history version %q", args[1])
+		}
+		ctx = wrapper.WithWho(ctx, wrapper.LocalWho())
+		return backend.Set(ctx, data, "", fmt.Sprintf("rolled back to history version '%s'", args[1]))
 
-	wrapper.Wrap(ctx, backend, os.Args[1:])
+	default:
+		return fmt.Errorf("unknown history subcommand %q", args[0])
+	}
 }
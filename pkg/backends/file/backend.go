@@ -3,6 +3,7 @@ package file
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
 	"os"
 )
@@ -51,6 +52,20 @@ func (f *Backend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error {
 	return nil
 }
 
+func (f *Backend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	return wrapper.LockInfo{}, nil
+}
+
 func (f *Backend) Lockable() bool {
 	return false
 }
+
+// ForWorkspace maps non-default workspaces onto a sibling file,
+// {filePath}.{workspace}.tfstate, leaving the default workspace's path
+// untouched for backward compatibility.
+func (f *Backend) ForWorkspace(name string) wrapper.Backend {
+	if name == wrapper.DefaultWorkspace {
+		return f
+	}
+	return &Backend{filePath: fmt.Sprintf("%s.%s.tfstate", f.filePath, name)}
+}
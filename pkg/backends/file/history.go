@@ -0,0 +1,102 @@
+package file
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+var _ wrapper.VersionedBackend = &Backend{}
+
+func (f *Backend) historyDir() string {
+	return f.filePath + ".history"
+}
+
+// Archive gzips data into {filePath}.history/{id}.tfstate.gz, with meta
+// stored alongside as {id}.json so ListVersions doesn't need to decompress
+// every snapshot just to read its metadata.
+func (f *Backend) Archive(ctx context.Context, data []byte, meta wrapper.VersionMeta) error {
+	dir := f.historyDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	snapshot, err := os.Create(filepath.Join(dir, meta.ID+".tfstate.gz"))
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	gz := gzip.NewWriter(snapshot)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, meta.ID+".json"), metaBytes, 0644)
+}
+
+func (f *Backend) ListVersions(ctx context.Context) ([]wrapper.VersionMeta, error) {
+	entries, err := os.ReadDir(f.historyDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []wrapper.VersionMeta
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(f.historyDir(), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var meta wrapper.VersionMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, err
+		}
+		versions = append(versions, meta)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Created.After(versions[j].Created)
+	})
+	return versions, nil
+}
+
+func (f *Backend) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	snapshot, err := os.Open(filepath.Join(f.historyDir(), id+".tfstate.gz"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+
+	gz, err := gzip.NewReader(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,68 @@
+package file
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	backend := &Backend{filePath: filepath.Join(t.TempDir(), "terraform.tfstate")}
+	ctx := context.Background()
+
+	older := wrapper.VersionMeta{ID: "20260101T000000.000000000", Created: time.Now().Add(-time.Hour), Who: "alice@example.com", Comment: "first"}
+	newer := wrapper.VersionMeta{ID: "20260102T000000.000000000", Created: time.Now(), Who: "bob@example.com", Comment: "second"}
+
+	if err := backend.Archive(ctx, []byte("old state"), older); err != nil {
+		t.Fatalf("Archive(older): %v", err)
+	}
+	if err := backend.Archive(ctx, []byte("newer state"), newer); err != nil {
+		t.Fatalf("Archive(newer): %v", err)
+	}
+
+	versions, err := backend.ListVersions(ctx)
+	if err != nil {
+		t.Fatalf("ListVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].ID != newer.ID {
+		t.Fatalf("expected most recent version first, got %q then %q", versions[0].ID, versions[1].ID)
+	}
+
+	data, err := backend.GetVersion(ctx, older.ID)
+	if err != nil {
+		t.Fatalf("GetVersion: %v", err)
+	}
+	if string(data) != "old state" {
+		t.Fatalf("got %q, want %q", data, "old state")
+	}
+}
+
+func TestListVersionsWithNoHistoryDir(t *testing.T) {
+	backend := &Backend{filePath: filepath.Join(t.TempDir(), "terraform.tfstate")}
+
+	versions, err := backend.ListVersions(context.Background())
+	if err != nil {
+		t.Fatalf("ListVersions: %v", err)
+	}
+	if versions != nil {
+		t.Fatalf("expected no versions, got %v", versions)
+	}
+}
+
+func TestGetVersionMissing(t *testing.T) {
+	backend := &Backend{filePath: filepath.Join(t.TempDir(), "terraform.tfstate")}
+
+	data, err := backend.GetVersion(context.Background(), "does-not-exist")
+	if err != nil {
+		t.Fatalf("GetVersion: %v", err)
+	}
+	if data != nil {
+		t.Fatalf("expected nil data for a missing version, got %v", data)
+	}
+}
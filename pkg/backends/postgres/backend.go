@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+	_ "github.com/lib/pq"
+)
+
+var _ wrapper.Backend = &Backend{}
+
+const (
+	defaultStateTable = "terraform_state"
+	defaultLocksTable = "terraform_locks"
+	defaultStateName  = "default"
+)
+
+type Backend struct {
+	connString string
+	stateTable string
+	locksTable string
+	stateName  string
+
+	db *sql.DB
+
+	// held while a lock is checked out, so UnLock can release the same
+	// session's advisory lock.
+	lockConn *sql.Conn
+}
+
+func (p *Backend) Config(ctx context.Context) error {
+	connString, ok := os.LookupEnv("TF_STATE_WRAPPER_PG_CONN")
+	if !ok {
+		return errors.New("'TF_STATE_WRAPPER_PG_CONN' must be set")
+	}
+	p.connString = connString
+
+	p.stateTable = defaultStateTable
+	if value, ok := os.LookupEnv("TF_STATE_WRAPPER_PG_STATE_TABLE"); ok {
+		p.stateTable = value
+	}
+
+	p.locksTable = defaultLocksTable
+	if value, ok := os.LookupEnv("TF_STATE_WRAPPER_PG_LOCKS_TABLE"); ok {
+		p.locksTable = value
+	}
+
+	p.stateName = defaultStateName
+	if value, ok := os.LookupEnv("TF_STATE_WRAPPER_PG_STATE_NAME"); ok {
+		p.stateName = value
+	}
+
+	db, err := sql.Open("postgres", p.connString)
+	if err != nil {
+		return err
+	}
+	p.db = db
+
+	return p.migrate(ctx)
+}
+
+// migrate creates the state and lock tables on first use so operators don't
+// need a separate schema setup step.
+func (p *Backend) migrate(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	name TEXT PRIMARY KEY,
+	data BYTEA NOT NULL
+)`, p.stateTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx, fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s (
+	name TEXT PRIMARY KEY,
+	info JSONB NOT NULL
+)`, p.locksTable))
+	return err
+}
+
+func (p *Backend) Get(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT data FROM %s WHERE name = $1`, p.stateTable), p.stateName).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (p *Backend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	_, err := p.db.ExecContext(ctx, fmt.Sprintf(`
+INSERT INTO %s (name, data) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data`, p.stateTable), p.stateName, data)
+	return err
+}
+
+func (p *Backend) Delete(ctx context.Context) error {
+	_, err := p.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, p.stateTable), p.stateName)
+	return err
+}
+
+// lockKey derives the pg_try_advisory_lock key from the state name, so
+// different states (and so different workspaces) don't contend on one lock.
+func (p *Backend) lockKey() int64 {
+	sum := sha256.Sum256([]byte(p.stateName))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+func (p *Backend) Lock(ctx context.Context, lockData wrapper.LockInfo) (bool, wrapper.LockInfo, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return false, wrapper.LockInfo{}, err
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, p.lockKey()).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return false, wrapper.LockInfo{}, err
+	}
+
+	if !acquired {
+		_ = conn.Close()
+		existing, err := p.currentLock(ctx)
+		if err != nil {
+			return false, wrapper.LockInfo{}, err
+		}
+		return false, existing, nil
+	}
+
+	data, err := json.Marshal(lockData)
+	if err != nil {
+		_ = p.release(ctx, conn)
+		return false, wrapper.LockInfo{}, err
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(`
+INSERT INTO %s (name, info) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET info = EXCLUDED.info`, p.locksTable), p.stateName, data)
+	if err != nil {
+		_ = p.release(ctx, conn)
+		return false, wrapper.LockInfo{}, err
+	}
+
+	p.lockConn = conn
+	return true, lockData, nil
+}
+
+func (p *Backend) currentLock(ctx context.Context) (wrapper.LockInfo, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT info FROM %s WHERE name = $1`, p.locksTable), p.stateName).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return wrapper.LockInfo{}, nil
+	}
+	if err != nil {
+		return wrapper.LockInfo{}, err
+	}
+	var lock wrapper.LockInfo
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return wrapper.LockInfo{}, err
+	}
+	return lock, nil
+}
+
+func (p *Backend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	return p.currentLock(ctx)
+}
+
+// release unlocks the advisory lock on conn and closes the connection back
+// to the pool.
+func (p *Backend) release(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, p.lockKey())
+	closeErr := conn.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (p *Backend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error {
+	if p.lockConn == nil {
+		return errors.New("no lock held")
+	}
+
+	existing, err := p.currentLock(ctx)
+	if err != nil {
+		return err
+	}
+	if existing.ID != lockData.ID {
+		return fmt.Errorf("lock ID %q does not match existing lock ID %q", lockData.ID, existing.ID)
+	}
+
+	_, err = p.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, p.locksTable), p.stateName)
+	if err != nil {
+		return err
+	}
+
+	conn := p.lockConn
+	p.lockConn = nil
+	return p.release(ctx, conn)
+}
+
+func (p *Backend) Lockable() bool {
+	return true
+}
+
+// ForWorkspace scopes the backend to a different row in the state table,
+// keyed by workspace name. The default workspace keeps whatever name Config
+// assigned it (TF_STATE_WRAPPER_PG_STATE_NAME, or "default").
+func (p *Backend) ForWorkspace(name string) wrapper.Backend {
+	if name == wrapper.DefaultWorkspace {
+		return p
+	}
+	scoped := *p
+	scoped.stateName = name
+	scoped.lockConn = nil
+	return &scoped
+}
@@ -0,0 +1,16 @@
+package postgres
+
+import "testing"
+
+func TestLockKeyIsStableAndDistinctPerStateName(t *testing.T) {
+	a := &Backend{stateName: "default"}
+	b := &Backend{stateName: "default"}
+	c := &Backend{stateName: "staging"}
+
+	if a.lockKey() != b.lockKey() {
+		t.Fatal("lockKey should be stable for the same state name")
+	}
+	if a.lockKey() == c.lockKey() {
+		t.Fatal("lockKey should differ between state names, or workspaces would contend on the same advisory lock")
+	}
+}
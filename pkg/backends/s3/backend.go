@@ -0,0 +1,258 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+var _ wrapper.Backend = &Backend{}
+
+type Backend struct {
+	bucket    string
+	key       string
+	region    string
+	kmsKeyID  string
+	sse       string
+	lockTable string
+
+	// deleteCurrentVersionOnly, when true, deletes the current object
+	// version outright instead of letting Delete write an S3 delete marker.
+	deleteCurrentVersionOnly bool
+
+	s3     *s3.Client
+	dynamo *dynamodb.Client
+}
+
+func (b *Backend) Config(ctx context.Context) error {
+	bucket, ok := os.LookupEnv("TF_STATE_WRAPPER_S3_BUCKET")
+	if !ok {
+		return errors.New("'TF_STATE_WRAPPER_S3_BUCKET' must be set")
+	}
+	b.bucket = bucket
+
+	key, ok := os.LookupEnv("TF_STATE_WRAPPER_S3_KEY")
+	if !ok {
+		return errors.New("'TF_STATE_WRAPPER_S3_KEY' must be set")
+	}
+	b.key = key
+
+	b.region = os.Getenv("TF_STATE_WRAPPER_S3_REGION")
+	b.kmsKeyID = os.Getenv("TF_STATE_WRAPPER_S3_KMS_KEY_ID")
+	b.sse = os.Getenv("TF_STATE_WRAPPER_S3_SSE")
+	b.deleteCurrentVersionOnly = os.Getenv("TF_STATE_WRAPPER_S3_DELETE_CURRENT_VERSION_ONLY") == "true"
+
+	lockTable, ok := os.LookupEnv("TF_STATE_WRAPPER_S3_DYNAMODB_TABLE")
+	if ok {
+		b.lockTable = lockTable
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if b.region != "" {
+		opts = append(opts, config.WithRegion(b.region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	b.s3 = s3.NewFromConfig(cfg)
+	b.dynamo = dynamodb.NewFromConfig(cfg)
+
+	return nil
+}
+
+func (b *Backend) Get(ctx context.Context) ([]byte, error) {
+	out, err := b.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+func (b *Backend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+		Body:   bytes.NewReader(data),
+	}
+
+	if b.sse != "" {
+		input.ServerSideEncryption = s3types.ServerSideEncryption(b.sse)
+	}
+	if b.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(b.kmsKeyID)
+	}
+
+	_, err := b.s3.PutObject(ctx, input)
+	return err
+}
+
+func (b *Backend) Delete(ctx context.Context) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key),
+	}
+
+	if b.deleteCurrentVersionOnly {
+		head, err := b.s3.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(b.bucket),
+			Key:    aws.String(b.key),
+		})
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		input.VersionId = head.VersionId
+	}
+
+	_, err := b.s3.DeleteObject(ctx, input)
+	return err
+}
+
+// lockID identifies this state's row in the DynamoDB lock table, matching
+// the schema Terraform's own s3 backend uses so an existing lock table can
+// be reused.
+func (b *Backend) lockID() string {
+	return fmt.Sprintf("%s/%s", b.bucket, b.key)
+}
+
+func (b *Backend) Lock(ctx context.Context, lockData wrapper.LockInfo) (bool, wrapper.LockInfo, error) {
+	info, err := json.Marshal(lockData)
+	if err != nil {
+		return false, wrapper.LockInfo{}, err
+	}
+
+	_, err = b.dynamo.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(b.lockTable),
+		Item: map[string]types.AttributeValue{
+			"LockID": &types.AttributeValueMemberS{Value: b.lockID()},
+			"Info":   &types.AttributeValueMemberS{Value: string(info)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(LockID)"),
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		existing, err := b.GetLock(ctx)
+		if err != nil {
+			return false, wrapper.LockInfo{}, err
+		}
+		return false, existing, nil
+	}
+	if err != nil {
+		return false, wrapper.LockInfo{}, err
+	}
+
+	return true, lockData, nil
+}
+
+// UnLock deletes the lock row only if it still holds the exact Info this
+// caller last observed, rather than a plain read-then-delete. Without that,
+// a lock broken with `terraform force-unlock` and immediately re-acquired by
+// someone else between the read and the delete would have its fresh lock
+// deleted out from under it instead of the stale one this caller meant to
+// release.
+func (b *Backend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error {
+	info, existing, err := b.currentLockInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if existing.ID != lockData.ID {
+		return fmt.Errorf("lock ID %q does not match existing lock ID %q", lockData.ID, existing.ID)
+	}
+
+	_, err = b.dynamo.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(b.lockTable),
+		Key: map[string]types.AttributeValue{
+			"LockID": &types.AttributeValueMemberS{Value: b.lockID()},
+		},
+		ConditionExpression: aws.String("Info = :info"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":info": &types.AttributeValueMemberS{Value: info},
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return errors.New("s3: lock was modified concurrently, refusing to unlock it")
+	}
+	return err
+}
+
+func (b *Backend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	_, lock, err := b.currentLockInfo(ctx)
+	return lock, err
+}
+
+// currentLockInfo returns both the decoded LockInfo and the raw Info string
+// it was decoded from, so callers that need to act on the lock (UnLock) can
+// condition their write on the exact value they observed.
+func (b *Backend) currentLockInfo(ctx context.Context) (string, wrapper.LockInfo, error) {
+	out, err := b.dynamo.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(b.lockTable),
+		Key: map[string]types.AttributeValue{
+			"LockID": &types.AttributeValueMemberS{Value: b.lockID()},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", wrapper.LockInfo{}, err
+	}
+	if out.Item == nil {
+		return "", wrapper.LockInfo{}, nil
+	}
+
+	attr, ok := out.Item["Info"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", wrapper.LockInfo{}, errors.New("s3: lock item is missing its Info attribute")
+	}
+
+	var lock wrapper.LockInfo
+	if err := json.Unmarshal([]byte(attr.Value), &lock); err != nil {
+		return "", wrapper.LockInfo{}, err
+	}
+	return attr.Value, lock, nil
+}
+
+func (b *Backend) Lockable() bool {
+	return b.lockTable != ""
+}
+
+// ForWorkspace scopes the object key the same way Terraform's own s3 backend
+// does, prefixing non-default workspaces with "env:/{workspace}/" so an
+// existing bucket layout keeps working.
+func (b *Backend) ForWorkspace(name string) wrapper.Backend {
+	if name == wrapper.DefaultWorkspace {
+		return b
+	}
+	scoped := *b
+	scoped.key = fmt.Sprintf("env:/%s/%s", name, b.key)
+	return &scoped
+}
@@ -0,0 +1,29 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+func TestLockIDIsBucketAndKey(t *testing.T) {
+	b := &Backend{bucket: "my-bucket", key: "terraform.tfstate"}
+
+	want := "my-bucket/terraform.tfstate"
+	if got := b.lockID(); got != want {
+		t.Fatalf("lockID() = %q, want %q", got, want)
+	}
+}
+
+func TestForWorkspaceScopesKeyLikeTerraformS3Backend(t *testing.T) {
+	root := &Backend{bucket: "my-bucket", key: "terraform.tfstate"}
+
+	scoped := root.ForWorkspace("staging").(*Backend)
+	if want := "env:/staging/terraform.tfstate"; scoped.key != want {
+		t.Fatalf("scoped key = %q, want %q", scoped.key, want)
+	}
+
+	if root.ForWorkspace(wrapper.DefaultWorkspace) != root {
+		t.Fatal("ForWorkspace(DefaultWorkspace) should return the receiver unchanged")
+	}
+}
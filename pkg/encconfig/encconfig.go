@@ -0,0 +1,62 @@
+// Package encconfig selects and applies an encryption.Backend based on
+// TF_STATE_WRAPPER_ENC_MODE, so every cmd/* entrypoint configures encryption
+// identically instead of each re-implementing the switch. It lives outside
+// pkg/encryption because pkg/encryption/awskms imports pkg/encryption to
+// satisfy encryption.KMS, and this package needs to import both.
+package encconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/encryption"
+	"github.com/Isonami/terraform-state-wrapper/pkg/encryption/awskms"
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+// Wrap layers encryption onto inner according to TF_STATE_WRAPPER_ENC_MODE
+// ("", "none", "static" or "envelope"). allowPlaintextRead is forwarded to
+// the resulting encryption.Backend so a store can be migrated from
+// unencrypted to encrypted without a big-bang rewrite.
+func Wrap(ctx context.Context, inner wrapper.Backend, allowPlaintextRead bool) (wrapper.Backend, error) {
+	switch mode := os.Getenv("TF_STATE_WRAPPER_ENC_MODE"); mode {
+	case "", "none":
+		return inner, nil
+
+	case "static":
+		encrypted, err := encryption.NewStaticKeyFromEnv(inner)
+		if err != nil {
+			return nil, err
+		}
+		encrypted.AllowPlaintextRead = allowPlaintextRead
+		return encrypted, nil
+
+	case "envelope":
+		kms, err := awskms.NewFromEnv(ctx)
+		if err != nil {
+			return nil, err
+		}
+		encrypted := encryption.NewEnvelope(inner, kms)
+		encrypted.AllowPlaintextRead = allowPlaintextRead
+		return encrypted, nil
+
+	default:
+		return nil, fmt.Errorf("unknown TF_STATE_WRAPPER_ENC_MODE %q, want '', 'none', 'static' or 'envelope'", mode)
+	}
+}
+
+// ExtractAllowPlaintextReadFlag pulls --allow-plaintext-read out of args. The
+// remaining args are handed straight through to the terraform child process,
+// which mustn't see a flag it doesn't understand.
+func ExtractAllowPlaintextReadFlag(args []string) (remaining []string, allowPlaintextRead bool) {
+	remaining = make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--allow-plaintext-read" {
+			allowPlaintextRead = true
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	return remaining, allowPlaintextRead
+}
@@ -0,0 +1,64 @@
+// Package awskms implements encryption.KMS against AWS Key Management
+// Service, for use as the KEK provider in envelope encryption.
+package awskms
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/encryption"
+)
+
+var _ encryption.KMS = &KMS{}
+
+type KMS struct {
+	client *kms.Client
+	keyID  string
+}
+
+func New(client *kms.Client, keyID string) *KMS {
+	return &KMS{client: client, keyID: keyID}
+}
+
+// NewFromEnv builds a KMS client from the default AWS config chain, keyed by
+// TF_STATE_WRAPPER_ENC_KMS_KEY_ID.
+func NewFromEnv(ctx context.Context) (*KMS, error) {
+	keyID, ok := os.LookupEnv("TF_STATE_WRAPPER_ENC_KMS_KEY_ID")
+	if !ok {
+		return nil, errors.New("'TF_STATE_WRAPPER_ENC_KMS_KEY_ID' must be set")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(kms.NewFromConfig(cfg), keyID), nil
+}
+
+func (k *KMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out, err := k.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(k.keyID),
+		Plaintext: plaintext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (k *KMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	out, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
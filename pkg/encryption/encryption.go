@@ -0,0 +1,294 @@
+// Package encryption wraps a wrapper.Backend so that the payload passed to
+// Set is encrypted before it reaches the underlying store, and the payload
+// returned by Get is decrypted transparently. Callers never see ciphertext.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+// KMS wraps a key-management service capable of encrypting and decrypting a
+// data-encryption key for envelope encryption.
+type KMS interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+const (
+	magic = "TSWE"
+
+	// versionStaticKey frames are magic || version || nonce || ciphertext,
+	// encrypted with a single static AES-256-GCM key.
+	versionStaticKey = 1
+
+	// versionEnvelope frames are
+	// magic || version || wrapped_dek_len || wrapped_dek || nonce || ciphertext.
+	// The DEK is generated per write and wrapped by a KMS-held KEK.
+	versionEnvelope = 2
+)
+
+// Backend decorates another wrapper.Backend with transparent encryption. The
+// embedded Backend satisfies Config/Delete/Lock/UnLock/GetLock/Lockable
+// unchanged; Get and Set are overridden to decrypt and encrypt, and
+// ForWorkspace, ListVersions and GetVersion are overridden to keep the
+// decorator in place.
+type Backend struct {
+	wrapper.Backend
+
+	key []byte
+	kms KMS
+
+	// AllowPlaintextRead lets Get pass through bytes that don't start with
+	// the frame magic, instead of erroring. Data that starts with the magic
+	// but carries an unknown frame version is still rejected. It is set
+	// from a --allow-plaintext-read flag so a store can be migrated from
+	// unencrypted to encrypted without a big-bang rewrite.
+	AllowPlaintextRead bool
+}
+
+// NewStaticKey wraps inner with AES-256-GCM encryption using a single static
+// key shared by every write.
+func NewStaticKey(inner wrapper.Backend, key []byte) *Backend {
+	return &Backend{Backend: inner, key: key}
+}
+
+// NewStaticKeyFromEnv is NewStaticKey with the key read from
+// TF_STATE_WRAPPER_ENC_KEY, base64-encoded.
+func NewStaticKeyFromEnv(inner wrapper.Backend) (*Backend, error) {
+	encoded, ok := os.LookupEnv("TF_STATE_WRAPPER_ENC_KEY")
+	if !ok {
+		return nil, errors.New("'TF_STATE_WRAPPER_ENC_KEY' must be set")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: decoding TF_STATE_WRAPPER_ENC_KEY: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("encryption: TF_STATE_WRAPPER_ENC_KEY must decode to 32 bytes for AES-256")
+	}
+
+	return NewStaticKey(inner, key), nil
+}
+
+// NewEnvelope wraps inner with envelope encryption: a fresh AES-256 DEK is
+// generated per write, used to encrypt the payload, and wrapped by kms
+// before being stored alongside the ciphertext.
+func NewEnvelope(inner wrapper.Backend, kms KMS) *Backend {
+	return &Backend{Backend: inner, kms: kms}
+}
+
+// ForWorkspace scopes the wrapped inner backend and re-applies this
+// Backend's encryption settings, so the embedded Backend.ForWorkspace
+// doesn't leak an unencrypted inner backend out of the decorator.
+func (b *Backend) ForWorkspace(name string) wrapper.Backend {
+	return &Backend{
+		Backend:            b.Backend.ForWorkspace(name),
+		key:                b.key,
+		kms:                b.kms,
+		AllowPlaintextRead: b.AllowPlaintextRead,
+	}
+}
+
+func (b *Backend) Get(ctx context.Context) ([]byte, error) {
+	data, err := b.Backend.Get(ctx)
+	if err != nil || data == nil {
+		return data, err
+	}
+	return b.decryptFrame(ctx, data)
+}
+
+// decryptFrame un-frames and decrypts data written by Set. It's also used by
+// GetVersion, since history archives the same framed bytes Set wrote.
+func (b *Backend) decryptFrame(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) < len(magic)+1 || string(data[:len(magic)]) != magic {
+		if b.AllowPlaintextRead {
+			return data, nil
+		}
+		return nil, errors.New("encryption: data is not framed ciphertext and AllowPlaintextRead is not set")
+	}
+
+	version := data[len(magic)]
+	rest := data[len(magic)+1:]
+
+	switch version {
+	case versionStaticKey:
+		return b.decryptStatic(rest)
+	case versionEnvelope:
+		return b.decryptEnvelope(ctx, rest)
+	default:
+		return nil, fmt.Errorf("encryption: unknown frame version %d", version)
+	}
+}
+
+// ListVersions and GetVersion forward to the inner backend when it supports
+// history. Embedding wrapper.Backend only promotes the methods of that
+// interface, not whatever extra methods the concrete backend underneath it
+// has, so without this a backend wrapped in both history.Wrap and
+// NewStaticKey/NewEnvelope would silently lose its history support.
+func (b *Backend) ListVersions(ctx context.Context) ([]wrapper.VersionMeta, error) {
+	versioned, ok := b.Backend.(wrapper.VersionedBackend)
+	if !ok {
+		return nil, errors.New("encryption: inner backend does not support history")
+	}
+	return versioned.ListVersions(ctx)
+}
+
+func (b *Backend) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	versioned, ok := b.Backend.(wrapper.VersionedBackend)
+	if !ok {
+		return nil, errors.New("encryption: inner backend does not support history")
+	}
+
+	data, err := versioned.GetVersion(ctx, id)
+	if err != nil || data == nil {
+		return data, err
+	}
+	return b.decryptFrame(ctx, data)
+}
+
+func (b *Backend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	var frame []byte
+	var err error
+	if b.kms != nil {
+		frame, err = b.encryptEnvelope(ctx, data)
+	} else {
+		frame, err = b.encryptStatic(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	return b.Backend.Set(ctx, frame, lockID, comment)
+}
+
+func (b *Backend) encryptStatic(plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(b.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomNonce(gcm)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	frame := make([]byte, 0, len(magic)+1+len(nonce)+len(ciphertext))
+	frame = append(frame, magic...)
+	frame = append(frame, versionStaticKey)
+	frame = append(frame, nonce...)
+	frame = append(frame, ciphertext...)
+	return frame, nil
+}
+
+func (b *Backend) decryptStatic(rest []byte) ([]byte, error) {
+	gcm, err := newGCM(b.key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("encryption: truncated frame")
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (b *Backend) encryptEnvelope(ctx context.Context, plaintext []byte) ([]byte, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, err
+	}
+	defer zero(dek)
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomNonce(gcm)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	wrappedDEK, err := b.kms.Encrypt(ctx, dek)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, 0, len(magic)+1+4+len(wrappedDEK)+len(nonce)+len(ciphertext))
+	frame = append(frame, magic...)
+	frame = append(frame, versionEnvelope)
+	var dekLen [4]byte
+	binary.BigEndian.PutUint32(dekLen[:], uint32(len(wrappedDEK)))
+	frame = append(frame, dekLen[:]...)
+	frame = append(frame, wrappedDEK...)
+	frame = append(frame, nonce...)
+	frame = append(frame, ciphertext...)
+	return frame, nil
+}
+
+func (b *Backend) decryptEnvelope(ctx context.Context, rest []byte) ([]byte, error) {
+	if len(rest) < 4 {
+		return nil, errors.New("encryption: truncated frame")
+	}
+	dekLen := binary.BigEndian.Uint32(rest[:4])
+	rest = rest[4:]
+
+	if uint64(len(rest)) < uint64(dekLen) {
+		return nil, errors.New("encryption: truncated frame")
+	}
+	wrappedDEK, rest := rest[:dekLen], rest[dekLen:]
+
+	dek, err := b.kms.Decrypt(ctx, wrappedDEK)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(dek)
+
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("encryption: truncated frame")
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomNonce(gcm cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,135 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+// memBackend is a minimal in-memory wrapper.Backend used to exercise the
+// encryption decorator without a real store behind it.
+type memBackend struct {
+	data []byte
+}
+
+func (m *memBackend) Config(ctx context.Context) error         { return nil }
+func (m *memBackend) Get(ctx context.Context) ([]byte, error)  { return m.data, nil }
+func (m *memBackend) Delete(ctx context.Context) error         { return nil }
+func (m *memBackend) Lockable() bool                           { return false }
+func (m *memBackend) ForWorkspace(name string) wrapper.Backend { return m }
+func (m *memBackend) Lock(ctx context.Context, lockData wrapper.LockInfo) (bool, wrapper.LockInfo, error) {
+	return true, lockData, nil
+}
+func (m *memBackend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error { return nil }
+func (m *memBackend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	return wrapper.LockInfo{}, nil
+}
+func (m *memBackend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	m.data = data
+	return nil
+}
+
+// fakeKMS wraps a DEK by XOR-ing it with a fixed pad, just enough to prove
+// Backend round-trips through a KMS dependency without needing real AWS.
+type fakeKMS struct {
+	pad byte
+}
+
+func (k *fakeKMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out := make([]byte, len(plaintext))
+	for i, b := range plaintext {
+		out[i] = b ^ k.pad
+	}
+	return out, nil
+}
+
+func (k *fakeKMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return k.Encrypt(ctx, ciphertext)
+}
+
+func TestStaticKeyRoundTrip(t *testing.T) {
+	inner := &memBackend{}
+	key := bytes.Repeat([]byte{0x42}, 32)
+	backend := NewStaticKey(inner, key)
+
+	ctx := context.Background()
+	want := []byte(`{"hello":"world"}`)
+
+	if err := backend.Set(ctx, want, "", ""); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if bytes.Equal(inner.data, want) {
+		t.Fatal("inner backend holds plaintext, expected ciphertext")
+	}
+
+	got, err := backend.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	inner := &memBackend{}
+	backend := NewEnvelope(inner, &fakeKMS{pad: 0x5a})
+
+	ctx := context.Background()
+	want := []byte(`{"hello":"envelope"}`)
+
+	if err := backend.Set(ctx, want, "", ""); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := backend.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestGetRejectsUnframedDataByDefault(t *testing.T) {
+	inner := &memBackend{data: []byte("plain terraform state")}
+	backend := NewStaticKey(inner, bytes.Repeat([]byte{0x01}, 32))
+
+	if _, err := backend.Get(context.Background()); err == nil {
+		t.Fatal("expected an error for unframed data without AllowPlaintextRead")
+	}
+}
+
+func TestGetAllowsUnframedDataWhenOptedIn(t *testing.T) {
+	plain := []byte("plain terraform state")
+	inner := &memBackend{data: plain}
+	backend := NewStaticKey(inner, bytes.Repeat([]byte{0x01}, 32))
+	backend.AllowPlaintextRead = true
+
+	got, err := backend.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("got %q, want %q", got, plain)
+	}
+}
+
+func TestDecryptFrameRejectsUnknownVersion(t *testing.T) {
+	backend := NewStaticKey(&memBackend{}, bytes.Repeat([]byte{0x01}, 32))
+
+	frame := append([]byte(magic), 0x99)
+	if _, err := backend.decryptFrame(context.Background(), frame); err == nil {
+		t.Fatal("expected an error for an unrecognised frame version")
+	}
+}
+
+func TestListVersionsErrorsWhenInnerBackendLacksHistory(t *testing.T) {
+	backend := NewStaticKey(&memBackend{}, bytes.Repeat([]byte{0x01}, 32))
+
+	if _, err := backend.ListVersions(context.Background()); err == nil {
+		t.Fatal("expected an error when the inner backend doesn't support history")
+	}
+}
@@ -0,0 +1,82 @@
+// Package history decorates a wrapper.Backend so every successful Set
+// archives the state it's about to overwrite, giving users a recovery path
+// when a bad apply corrupts state.
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+// Archiver is implemented by a Backend that can store a historical snapshot
+// of its own state, keyed by the ID Backend.Set assigns it. The file backend
+// implements this as a {path}.history/ directory of gzipped snapshots.
+type Archiver interface {
+	Archive(ctx context.Context, data []byte, meta wrapper.VersionMeta) error
+}
+
+var _ wrapper.Backend = &Backend{}
+var _ wrapper.VersionedBackend = &Backend{}
+
+// Backend decorates another wrapper.Backend so every successful Set first
+// archives the state it's about to overwrite.
+type Backend struct {
+	wrapper.Backend
+	archiver Archiver
+}
+
+// Wrap decorates inner with history archiving. inner must implement both
+// Archiver and wrapper.VersionedBackend; Wrap panics otherwise, since a
+// history-wrapped backend with nowhere to put history is a configuration
+// mistake, not a runtime condition to handle gracefully.
+func Wrap(inner wrapper.Backend) *Backend {
+	archiver, ok := inner.(Archiver)
+	if !ok {
+		panic("history: backend does not implement history.Archiver")
+	}
+	if _, ok := inner.(wrapper.VersionedBackend); !ok {
+		panic("history: backend does not implement wrapper.VersionedBackend")
+	}
+	return &Backend{Backend: inner, archiver: archiver}
+}
+
+func (b *Backend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	previous, err := b.Backend.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil {
+		meta := wrapper.VersionMeta{
+			ID:      time.Now().UTC().Format("20060102T150405.000000000"),
+			Created: time.Now().UTC(),
+			Who:     wrapper.WhoFromContext(ctx),
+			Comment: comment,
+		}
+
+		if err := b.archiver.Archive(ctx, previous, meta); err != nil {
+			return err
+		}
+	}
+
+	return b.Backend.Set(ctx, data, lockID, comment)
+}
+
+// ListVersions and GetVersion satisfy wrapper.VersionedBackend by delegating
+// to the inner backend, which Wrap already verified implements it.
+
+func (b *Backend) ListVersions(ctx context.Context) ([]wrapper.VersionMeta, error) {
+	return b.Backend.(wrapper.VersionedBackend).ListVersions(ctx)
+}
+
+func (b *Backend) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	return b.Backend.(wrapper.VersionedBackend).GetVersion(ctx, id)
+}
+
+// ForWorkspace re-wraps the inner backend's own workspace-scoped instance, so
+// each workspace archives into its own history.
+func (b *Backend) ForWorkspace(name string) wrapper.Backend {
+	return Wrap(b.Backend.ForWorkspace(name))
+}
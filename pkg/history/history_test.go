@@ -0,0 +1,105 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Isonami/terraform-state-wrapper/pkg/wrapper"
+)
+
+// archivingBackend is a minimal in-memory wrapper.Backend that also
+// implements Archiver and wrapper.VersionedBackend, so Wrap accepts it.
+type archivingBackend struct {
+	data     []byte
+	archived []wrapper.VersionMeta
+}
+
+func (b *archivingBackend) Config(ctx context.Context) error        { return nil }
+func (b *archivingBackend) Get(ctx context.Context) ([]byte, error) { return b.data, nil }
+func (b *archivingBackend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	b.data = data
+	return nil
+}
+func (b *archivingBackend) Delete(ctx context.Context) error { return nil }
+func (b *archivingBackend) Lock(ctx context.Context, lockData wrapper.LockInfo) (bool, wrapper.LockInfo, error) {
+	return true, lockData, nil
+}
+func (b *archivingBackend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error { return nil }
+func (b *archivingBackend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	return wrapper.LockInfo{}, nil
+}
+func (b *archivingBackend) Lockable() bool                           { return false }
+func (b *archivingBackend) ForWorkspace(name string) wrapper.Backend { return b }
+
+func (b *archivingBackend) Archive(ctx context.Context, data []byte, meta wrapper.VersionMeta) error {
+	b.archived = append(b.archived, meta)
+	return nil
+}
+func (b *archivingBackend) ListVersions(ctx context.Context) ([]wrapper.VersionMeta, error) {
+	return b.archived, nil
+}
+func (b *archivingBackend) GetVersion(ctx context.Context, id string) ([]byte, error) {
+	return nil, nil
+}
+
+func TestSetArchivesPreviousVersion(t *testing.T) {
+	inner := &archivingBackend{data: []byte("version one")}
+	backend := Wrap(inner)
+	ctx := context.Background()
+
+	if err := backend.Set(ctx, []byte("version two"), "", "second write"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if len(inner.archived) != 1 {
+		t.Fatalf("expected 1 archived version, got %d", len(inner.archived))
+	}
+	if inner.archived[0].Comment != "second write" {
+		t.Fatalf("got comment %q, want %q", inner.archived[0].Comment, "second write")
+	}
+	if string(inner.data) != "version two" {
+		t.Fatalf("inner backend holds %q, want %q", inner.data, "version two")
+	}
+}
+
+func TestSetSkipsArchivingWhenNoPreviousVersion(t *testing.T) {
+	inner := &archivingBackend{}
+	backend := Wrap(inner)
+
+	if err := backend.Set(context.Background(), []byte("first write"), "", "initial"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if len(inner.archived) != 0 {
+		t.Fatalf("expected no archived versions for an initial write, got %d", len(inner.archived))
+	}
+}
+
+func TestWrapPanicsOnNonArchiver(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Wrap to panic on a backend that doesn't implement Archiver")
+		}
+	}()
+
+	Wrap(&nonVersionedBackend{})
+}
+
+// nonVersionedBackend implements wrapper.Backend only, not Archiver or
+// wrapper.VersionedBackend, to exercise Wrap's panic guard.
+type nonVersionedBackend struct{}
+
+func (nonVersionedBackend) Config(ctx context.Context) error        { return nil }
+func (nonVersionedBackend) Get(ctx context.Context) ([]byte, error) { return nil, nil }
+func (nonVersionedBackend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	return nil
+}
+func (nonVersionedBackend) Delete(ctx context.Context) error { return nil }
+func (nonVersionedBackend) Lock(ctx context.Context, lockData wrapper.LockInfo) (bool, wrapper.LockInfo, error) {
+	return true, lockData, nil
+}
+func (nonVersionedBackend) UnLock(ctx context.Context, lockData wrapper.LockInfo) error { return nil }
+func (nonVersionedBackend) GetLock(ctx context.Context) (wrapper.LockInfo, error) {
+	return wrapper.LockInfo{}, nil
+}
+func (nonVersionedBackend) Lockable() bool                             { return false }
+func (b nonVersionedBackend) ForWorkspace(name string) wrapper.Backend { return b }
@@ -0,0 +1,31 @@
+package wrapper
+
+import (
+	"context"
+	"time"
+)
+
+// VersionMeta describes one snapshot captured by a VersionedBackend.
+type VersionMeta struct {
+	// ID identifies the version, assigned by whatever archived it.
+	ID string
+
+	// Created is when the snapshot was taken.
+	Created time.Time
+
+	// Who is the LockInfo.Who of whoever held the lock at the time, when
+	// available.
+	Who string
+
+	// Comment is the same commit comment backendHandler computes for Set.
+	Comment string
+}
+
+// VersionedBackend is implemented by backends that retain past versions of
+// their state. It's optional: most Backend implementations don't support
+// history, so callers should check for it with a type assertion rather than
+// relying on it being present.
+type VersionedBackend interface {
+	ListVersions(ctx context.Context) ([]VersionMeta, error)
+	GetVersion(ctx context.Context, id string) ([]byte, error)
+}
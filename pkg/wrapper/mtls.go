@@ -0,0 +1,112 @@
+package wrapper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+// generateMTLSMaterial creates an ephemeral CA, a server certificate for
+// host, and a client certificate, all signed for the lifetime of a single
+// wrapper invocation. It returns a tls.Config ready to serve the loopback
+// listener plus the PEM-encoded CA and client credentials to hand to the
+// terraform child process.
+func generateMTLSMaterial(host string) (serverTLSConfig *tls.Config, caCertPEM, clientCertPEM, clientKeyPEM []byte, err error) {
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "terraform-state-wrapper"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	serverCertPEM, serverKeyPEM, err := issueCertificate(caCert, caKey, now, x509.ExtKeyUsageServerAuth, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		IPAddresses:  []net.IP{net.ParseIP(host)},
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	clientCertPEM, clientKeyPEM, err = issueCertificate(caCert, caKey, now, x509.ExtKeyUsageClientAuth, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "terraform"},
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	serverCertificate, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	caCertPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+
+	caPool := x509.NewCertPool()
+	caPool.AddCert(caCert)
+
+	serverTLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    caPool,
+	}
+
+	return serverTLSConfig, caCertPEM, clientCertPEM, clientKeyPEM, nil
+}
+
+// issueCertificate fills in the validity window and extended key usage on
+// template, signs it with the given CA, and returns the PEM-encoded
+// certificate and private key.
+func issueCertificate(caCert *x509.Certificate, caKey *ecdsa.PrivateKey, now time.Time, extKeyUsage x509.ExtKeyUsage, template *x509.Certificate) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.NotBefore = now.Add(-time.Minute)
+	template.NotAfter = now.Add(24 * time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{extKeyUsage}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
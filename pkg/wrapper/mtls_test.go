@@ -0,0 +1,102 @@
+package wrapper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRandSeqGeneratesDistinctPasswordsOfRequestedLength(t *testing.T) {
+	a := randSeq(32)
+	b := randSeq(32)
+
+	if len(a) != 32 || len(b) != 32 {
+		t.Fatalf("expected length 32, got %d and %d", len(a), len(b))
+	}
+	if a == b {
+		t.Fatal("two calls to randSeq produced the same password")
+	}
+}
+
+// TestGenerateMTLSMaterialHandshakes proves the CA, server cert and client
+// cert generateMTLSMaterial produces actually chain correctly, by running a
+// real mTLS handshake over a loopback listener.
+func TestGenerateMTLSMaterialHandshakes(t *testing.T) {
+	serverTLSConfig, caCertPEM, clientCertPEM, clientKeyPEM, err := generateMTLSMaterial("127.0.0.1")
+	if err != nil {
+		t.Fatalf("generateMTLSMaterial: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server.TLS = serverTLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair: %v", err)
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCertPEM) {
+		t.Fatal("failed to parse CA cert PEM")
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				Certificates: []tls.Certificate{clientCert},
+				RootCAs:      caPool,
+			},
+		},
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("client.Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestGenerateMTLSMaterialRejectsUnauthenticatedClient(t *testing.T) {
+	serverTLSConfig, _, _, _, err := generateMTLSMaterial("127.0.0.1")
+	if err != nil {
+		t.Fatalf("generateMTLSMaterial: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server.TLS = serverTLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	// No client certificate presented: the handshake itself must fail given
+	// RequireAndVerifyClientCert.
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("expected the handshake to fail without a client certificate")
+	}
+}
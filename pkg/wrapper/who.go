@@ -0,0 +1,40 @@
+package wrapper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+)
+
+type whoKey struct{}
+
+// WithWho attaches who to ctx, so a Backend.Set implementation several layers
+// down (e.g. history.Backend, which can't see the lock that was held for this
+// request) can still record who made the change.
+func WithWho(ctx context.Context, who string) context.Context {
+	return context.WithValue(ctx, whoKey{}, who)
+}
+
+// WhoFromContext returns the who attached by WithWho, or "" if none was set.
+func WhoFromContext(ctx context.Context) string {
+	who, _ := ctx.Value(whoKey{}).(string)
+	return who
+}
+
+// LocalWho returns "user@hostname" for the process running the wrapper,
+// matching the format LockInfo.Who uses. It's the best we can do for
+// non-lockable backends, which never see a LockInfo to read Who from.
+func LocalWho() string {
+	systemUser, err := user.Current()
+	if err != nil {
+		return ""
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return systemUser.Username
+	}
+
+	return fmt.Sprintf("%s@%s", systemUser.Username, hostname)
+}
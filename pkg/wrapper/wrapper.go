@@ -2,21 +2,30 @@ package wrapper
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
+	"math/big"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
+	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultWorkspace is the workspace used when the caller doesn't select one,
+// e.g. via TF_WORKSPACE. It must behave identically to how a Backend behaved
+// before ForWorkspace was introduced.
+const DefaultWorkspace = "default"
+
 type LockInfo struct {
 	// Unique ID for the lock. NewLockInfo provides a random ID, but this may
 	// be overridden by the lock implementation. The final value of ID will be
@@ -49,7 +58,17 @@ type Backend interface {
 	Delete(ctx context.Context) error
 	Lock(ctx context.Context, lockData LockInfo) (bool, LockInfo, error)
 	UnLock(ctx context.Context, lockData LockInfo) error
+	// GetLock returns the lock currently held, if any. Backends that are not
+	// Lockable() may return the zero LockInfo and a nil error.
+	GetLock(ctx context.Context) (LockInfo, error)
 	Lockable() bool
+
+	// ForWorkspace returns a Backend scoped to the named workspace, so a
+	// single wrapper invocation can serve `terraform workspace` commands
+	// against more than one state. Backends that don't support multiple
+	// workspaces may just return themselves. Calling ForWorkspace(DefaultWorkspace)
+	// must behave exactly like the receiver did before workspaces existed.
+	ForWorkspace(name string) Backend
 }
 
 func createListener() (l net.Listener, close func(), err error) {
@@ -64,27 +83,68 @@ func createListener() (l net.Listener, close func(), err error) {
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
+// randSeq generates a password for the loopback basic-auth credential. It
+// uses crypto/rand rather than math/rand: a math/rand source seeded from
+// wall-clock time is predictable enough for a local attacker who knows
+// roughly when the wrapper started to brute-force.
 func randSeq(n int) string {
-	seed := rand.New(rand.NewSource(time.Now().UnixNano()))
-
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letters[seed.Intn(len(letters))]
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b[i] = letters[idx.Int64()]
 	}
 	return string(b)
 }
 
-func backendHandler(ctx context.Context, backend Backend, action, authUser, authPassword string) http.HandlerFunc {
+// workspaceFromPath extracts the workspace name from a "/backend/{workspace}"
+// request path, defaulting to DefaultWorkspace when none is given.
+func workspaceFromPath(path string) string {
+	const prefix = "/backend/"
+	if !strings.HasPrefix(path, prefix) {
+		return DefaultWorkspace
+	}
+	if name := strings.TrimPrefix(path, prefix); name != "" {
+		return name
+	}
+	return DefaultWorkspace
+}
+
+func backendHandler(ctx context.Context, root Backend, action, authUser, authPassword string) http.HandlerFunc {
 	systemUser, _ := user.Current()
 
 	comment := fmt.Sprintf("updated with terraform '%s' by '%s'", action, systemUser.Name)
 
+	// Resolve each workspace's Backend once and reuse it for every request,
+	// rather than calling root.ForWorkspace on every request. Backends that
+	// stash per-connection state across a Lock/UnLock pair (e.g. postgres'
+	// advisory-lock session) need the same instance to see both halves of
+	// that pair.
+	var scopedMu sync.Mutex
+	scoped := map[string]Backend{}
+
+	resolveBackend := func(workspace string) Backend {
+		scopedMu.Lock()
+		defer scopedMu.Unlock()
+
+		if b, ok := scoped[workspace]; ok {
+			return b
+		}
+		b := root.ForWorkspace(workspace)
+		scoped[workspace] = b
+		return b
+	}
+
 	return func(writer http.ResponseWriter, request *http.Request) {
 		userRequest, passwordRequest, ok := request.BasicAuth()
 		if !ok || userRequest != authUser || passwordRequest != authPassword {
 			http.Error(writer, "Unauthorized", http.StatusUnauthorized)
 		}
 
+		backend := resolveBackend(workspaceFromPath(request.URL.Path))
+
 		returnError := func(err error) {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 		}
@@ -113,7 +173,23 @@ func backendHandler(ctx context.Context, backend Backend, action, authUser, auth
 
 			lockId := request.URL.Query().Get("ID")
 
-			err = backend.Set(ctx, data, lockId, comment)
+			who := LocalWho()
+			if backend.Lockable() {
+				currentLock, err := backend.GetLock(ctx)
+				if err != nil {
+					returnError(err)
+					return
+				}
+				if currentLock.ID != lockId {
+					http.Error(writer, "lock ID does not match existing lock", http.StatusConflict)
+					return
+				}
+				if currentLock.Who != "" {
+					who = currentLock.Who
+				}
+			}
+
+			err = backend.Set(WithWho(ctx, who), data, lockId, comment)
 			if err != nil {
 				returnError(err)
 			}
@@ -197,17 +273,47 @@ func Wrap(ctx context.Context, backend Backend, args []string) {
 	}
 	defer closeListener()
 
+	workspace := os.Getenv("TF_WORKSPACE")
+	if workspace == "" {
+		workspace = DefaultWorkspace
+	}
+
+	scheme := "http"
+	host := listener.Addr().(*net.TCPAddr).IP.String()
+
+	// Opt-in mTLS: without it, any other local user on the machine can hit
+	// 127.0.0.1:PORT, so this closes that window for anyone who turns it on.
+	if os.Getenv("TF_STATE_WRAPPER_MTLS") == "true" {
+		serverTLSConfig, caCertPEM, clientCertPEM, clientKeyPEM, err := generateMTLSMaterial(host)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		listener = tls.NewListener(listener, serverTLSConfig)
+		scheme = "https"
+
+		for name, value := range map[string]string{
+			"TF_HTTP_CLIENT_CERTIFICATE_PEM": string(clientCertPEM),
+			"TF_HTTP_CLIENT_PRIVATE_KEY_PEM": string(clientKeyPEM),
+			"TF_HTTP_CA_CERTIFICATE_PEM":     string(caCertPEM),
+		} {
+			if err := os.Setenv(name, value); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
 	backendUrl := url.URL{
-		Scheme: "http",
+		Scheme: scheme,
 		Host:   fmt.Sprintf("127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port),
-		Path:   "/backend",
+		Path:   "/backend/" + workspace,
 	}
 
 	authUser := "auth"
 	authPassword := randSeq(32)
 
 	mux := http.NewServeMux()
-	mux.Handle(backendUrl.Path, backendHandler(ctx, backend, terraformAction, authUser, authPassword))
+	mux.Handle("/backend/", backendHandler(ctx, backend, terraformAction, authUser, authPassword))
 
 	go func() {
 		closedErr := http.ErrServerClosed
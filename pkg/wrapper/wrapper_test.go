@@ -0,0 +1,171 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeBackend is a minimal, in-memory Backend used to exercise
+// backendHandler without a real store behind it.
+type fakeBackend struct {
+	data []byte
+
+	lock      LockInfo
+	lockable  bool
+	lockErr   error
+	setCalled bool
+}
+
+func (f *fakeBackend) Config(ctx context.Context) error { return nil }
+func (f *fakeBackend) Get(ctx context.Context) ([]byte, error) {
+	return f.data, nil
+}
+func (f *fakeBackend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	f.setCalled = true
+	f.data = data
+	return nil
+}
+func (f *fakeBackend) Delete(ctx context.Context) error { return nil }
+func (f *fakeBackend) Lock(ctx context.Context, lockData LockInfo) (bool, LockInfo, error) {
+	return true, lockData, nil
+}
+func (f *fakeBackend) UnLock(ctx context.Context, lockData LockInfo) error { return nil }
+func (f *fakeBackend) GetLock(ctx context.Context) (LockInfo, error) {
+	return f.lock, f.lockErr
+}
+func (f *fakeBackend) Lockable() bool                   { return f.lockable }
+func (f *fakeBackend) ForWorkspace(name string) Backend { return f }
+
+func postState(t *testing.T, backend *fakeBackend, lockID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := backendHandler(context.Background(), backend, "apply", "auth", "password")
+
+	req := httptest.NewRequest(http.MethodPost, "/backend/default?ID="+lockID, strings.NewReader("new-state"))
+	req.SetBasicAuth("auth", "password")
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+func TestBackendHandlerPostRejectsMismatchedLockID(t *testing.T) {
+	backend := &fakeBackend{lockable: true, lock: LockInfo{ID: "held-by-someone-else"}}
+
+	recorder := postState(t, backend, "wrong-id")
+
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", recorder.Code)
+	}
+	if backend.setCalled {
+		t.Fatal("Set must not be called when the lock ID doesn't match")
+	}
+}
+
+func TestBackendHandlerPostAcceptsMatchingLockID(t *testing.T) {
+	backend := &fakeBackend{lockable: true, lock: LockInfo{ID: "the-lock"}}
+
+	recorder := postState(t, backend, "the-lock")
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", recorder.Code)
+	}
+	if !backend.setCalled {
+		t.Fatal("Set should be called when the lock ID matches")
+	}
+}
+
+func TestBackendHandlerPostStopsOnGetLockError(t *testing.T) {
+	backend := &fakeBackend{lockable: true, lockErr: errors.New("boom")}
+
+	// An empty query-string ID would equal the zero-value LockInfo.ID if the
+	// handler fell through after the GetLock error, so this also guards
+	// against the fail-open bug where a missing `return` let Set run anyway.
+	recorder := postState(t, backend, "")
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+	if backend.setCalled {
+		t.Fatal("Set must not be called when GetLock fails")
+	}
+}
+
+func TestWorkspaceFromPath(t *testing.T) {
+	cases := map[string]string{
+		"/backend/default": "default",
+		"/backend/prod":    "prod",
+		"/backend/":        DefaultWorkspace,
+		"/other":           DefaultWorkspace,
+	}
+	for path, want := range cases {
+		if got := workspaceFromPath(path); got != want {
+			t.Errorf("workspaceFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// scopingBackend mimics a backend like postgres.Backend, whose ForWorkspace
+// returns a brand-new instance that doesn't carry over connection-level
+// state (held). held only survives across a Lock/UnLock pair if the same
+// instance handles both calls.
+type scopingBackend struct {
+	forWorkspaceCalls *int
+	held              bool
+}
+
+func (s *scopingBackend) Config(ctx context.Context) error        { return nil }
+func (s *scopingBackend) Get(ctx context.Context) ([]byte, error) { return nil, nil }
+func (s *scopingBackend) Set(ctx context.Context, data []byte, lockID, comment string) error {
+	return nil
+}
+func (s *scopingBackend) Delete(ctx context.Context) error { return nil }
+func (s *scopingBackend) Lock(ctx context.Context, lockData LockInfo) (bool, LockInfo, error) {
+	s.held = true
+	return true, lockData, nil
+}
+func (s *scopingBackend) UnLock(ctx context.Context, lockData LockInfo) error {
+	if !s.held {
+		return errors.New("no lock held")
+	}
+	s.held = false
+	return nil
+}
+func (s *scopingBackend) GetLock(ctx context.Context) (LockInfo, error) { return LockInfo{}, nil }
+func (s *scopingBackend) Lockable() bool                                { return true }
+func (s *scopingBackend) ForWorkspace(name string) Backend {
+	*s.forWorkspaceCalls++
+	return &scopingBackend{forWorkspaceCalls: s.forWorkspaceCalls}
+}
+
+func lockRequest(t *testing.T, handler http.HandlerFunc, method, workspace string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/backend/"+workspace, strings.NewReader("{}"))
+	req.SetBasicAuth("auth", "password")
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+	return recorder
+}
+
+func TestBackendHandlerReusesScopedBackendAcrossRequests(t *testing.T) {
+	calls := 0
+	root := &scopingBackend{forWorkspaceCalls: &calls}
+	handler := backendHandler(context.Background(), root, "apply", "auth", "password")
+
+	if recorder := lockRequest(t, handler, "LOCK", "prod"); recorder.Code != http.StatusOK {
+		t.Fatalf("LOCK: expected 200, got %d", recorder.Code)
+	}
+	if recorder := lockRequest(t, handler, "UNLOCK", "prod"); recorder.Code != http.StatusOK {
+		t.Fatalf("UNLOCK: expected 200, got %d (lock state wasn't preserved across requests)", recorder.Code)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected ForWorkspace to be called once for repeated requests to the same workspace, got %d", calls)
+	}
+}